@@ -0,0 +1,74 @@
+package keylock
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// newTestEtcdKeyLock dials the etcd cluster listed in KEYLOCK_ETCD_ENDPOINTS
+// and skips the test if that env var isn't set, since these tests need a
+// real cluster to coordinate against.
+func newTestEtcdKeyLock(t *testing.T) *EtcdKeyLock {
+	t.Helper()
+	endpoints := os.Getenv("KEYLOCK_ETCD_ENDPOINTS")
+	if endpoints == "" {
+		t.Skip("KEYLOCK_ETCD_ENDPOINTS not set; skipping etcd-backed tests")
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   strings.Split(endpoints, ","),
+		DialTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	kl, err := NewEtcd(client, WithEtcdTTL(2))
+	require.NoError(t, err)
+	t.Cleanup(func() { kl.Close() })
+	return kl
+}
+
+func TestEtcdKeyLockBasicLocking(t *testing.T) {
+	kl := newTestEtcdKeyLock(t)
+
+	assert.True(t, kl.TryLock("etcd-test1"))
+	assert.False(t, kl.TryLock("etcd-test1"), "TryLock should fail while the key is already held")
+
+	kl.Unlock("etcd-test1")
+	assert.True(t, kl.TryLock("etcd-test1"), "TryLock should succeed once the key is released")
+	kl.Unlock("etcd-test1")
+}
+
+func TestEtcdKeyLockBlocksConcurrentCaller(t *testing.T) {
+	kl := newTestEtcdKeyLock(t)
+
+	kl.Lock("etcd-test2")
+
+	var acquired atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		kl.Lock("etcd-test2")
+		acquired.Store(true)
+		kl.Unlock("etcd-test2")
+		close(done)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, acquired.Load(), "second Lock should block while the first is held")
+
+	kl.Unlock("etcd-test2")
+	<-done
+	assert.True(t, acquired.Load())
+}
+
+func TestEtcdKeyLockSession(t *testing.T) {
+	kl := newTestEtcdKeyLock(t)
+	assert.NotNil(t, kl.Session(), "a freshly created EtcdKeyLock should expose its session")
+}
@@ -1,21 +1,50 @@
 package keylock
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 // KeyLock provides a lock mechanism associated with a string key.
-// Internally, it uses spinlocks and sync.Map to manage locks per key.
+// Internally, it uses spinlocks and a set of sharded, mutex-guarded maps to
+// manage locks per key, so unrelated keys don't contend on the same map.
 type KeyLock struct {
-	locks    sync.Map // map[string]*spinLock
-	count    int32    // number of currently held locks
-	maxSpins int32    // max backoff spins while waiting for lock
+	initOnce  sync.Once
+	shards    []shard
+	shardMask uint32
+	count     int32 // number of currently held write locks
+	readCount int32 // number of currently held read locks
+	maxSpins  int32 // max backoff spins while waiting for lock
+	reentrant bool  // if true, Lock/Unlock are reentrant per goroutine
 }
 
-// spinLock represents a lightweight spin-based lock using atomic operations.
-type spinLock int32
+// spinLock represents a lightweight spin-based read/write lock using atomic
+// operations, modeled on sync.RWMutex. state encodes both the writer flag
+// and the reader count in a single word so the fast path stays lock-free:
+// 0 means unlocked, -1 means write-locked, and n > 0 means n readers hold
+// the lock. waiters tracks how many goroutines currently hold an interest
+// in this key, either spinning to acquire it or about to; Cleanup must not
+// remove an entry while waiters is non-zero, or a waiter could CAS against
+// a *spinLock that's no longer reachable from its shard's map.
+//
+// writersWaiting counts goroutines currently trying to acquire the key for
+// writing, so that RLock/TryRLock can stop granting new readers as soon as
+// one shows up, the same way sync.RWMutex stops new readers once a writer
+// is pending. Without this, a steady stream of overlapping readers can keep
+// state away from 0 indefinitely and starve a writer.
+type spinLock struct {
+	state          int64
+	waiters        int32
+	writersWaiting int32
+
+	// mu guards owner/depth, which are only touched in reentrant mode.
+	mu    sync.Mutex
+	owner int64
+	depth int32
+}
 
 const (
 	defaultMaxSpins = 16 // Default maximum number of spin attempts
@@ -24,7 +53,9 @@ const (
 // New creates a new KeyLock instance with default configuration.
 func New() *KeyLock {
 	return &KeyLock{
-		maxSpins: defaultMaxSpins,
+		maxSpins:  defaultMaxSpins,
+		shards:    newShards(defaultShardCount),
+		shardMask: uint32(defaultShardCount - 1),
 	}
 }
 
@@ -34,25 +65,67 @@ func (kl *KeyLock) WithMaxSpins(max int32) *KeyLock {
 	return kl
 }
 
-// TryLock attempts to acquire the lock for the given key immediately.
-// Returns true if the lock was successfully acquired, false otherwise.
-func (kl *KeyLock) TryLock(key string) bool {
-	value, _ := kl.locks.LoadOrStore(key, new(spinLock))
-	lock := value.(*spinLock)
-	if atomic.CompareAndSwapInt32((*int32)(lock), 0, 1) {
-		atomic.AddInt32(&kl.count, 1)
-		return true
+// resolve returns the spinLock for key, creating it if it doesn't exist
+// yet, and records the caller as a waiter on it. The lookup-or-create and
+// the waiter registration happen under the shard's mutex as one step, so
+// releaseIfIdle can never unlink the entry in the gap between the two;
+// every call must be matched with exactly one atomic.AddInt32(&lock.waiters, -1)
+// once the caller's acquisition attempt (successful or not) concludes.
+func (kl *KeyLock) resolve(key string) *spinLock {
+	s := kl.shardFor(key)
+	s.mu.Lock()
+	lock, ok := s.locks[key]
+	if !ok {
+		lock = new(spinLock)
+		s.locks[key] = lock
 	}
-	return false
+	atomic.AddInt32(&lock.waiters, 1)
+	s.mu.Unlock()
+	return lock
 }
 
-// Lock acquires the lock for the given key, using exponential backoff if necessary.
-func (kl *KeyLock) Lock(key string) {
-	value, _ := kl.locks.LoadOrStore(key, new(spinLock))
-	lock := value.(*spinLock)
+// find returns the spinLock already stored for key, if any, without
+// registering a waiter on it. It's used by the release side (Unlock,
+// RUnlock, unlockReentrant), which already holds the lock rather than
+// waiting on it.
+func (kl *KeyLock) find(key string) (*spinLock, bool) {
+	s := kl.shardFor(key)
+	s.mu.Lock()
+	lock, ok := s.locks[key]
+	s.mu.Unlock()
+	return lock, ok
+}
 
+// releaseIfIdle deletes key's entry from its shard once it's fully
+// unlocked and nothing is waiting on it, so long-running services with
+// high-cardinality keys don't accumulate unbounded *spinLock values. The
+// idle check and the delete happen under the shard's mutex, the same
+// critical section resolve uses to register a waiter, so a waiter can
+// never be left holding a *spinLock that's just been unlinked: either its
+// resolve call already bumped waiters before this check, or this check
+// (and the delete, if any) completed before that resolve call could start.
+func (kl *KeyLock) releaseIfIdle(key string, lock *spinLock) {
+	s := kl.shardFor(key)
+	s.mu.Lock()
+	if atomic.LoadInt64(&lock.state) == 0 && atomic.LoadInt32(&lock.waiters) == 0 {
+		if cur, ok := s.locks[key]; ok && cur == lock {
+			delete(s.locks, key)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// spin blocks the calling goroutine until acquire reports success, backing
+// off exponentially between attempts and yielding the processor, or until
+// ctx is done.
+func (kl *KeyLock) spin(ctx context.Context, lock *spinLock, acquire func() bool) error {
 	backoff := 1
-	for !atomic.CompareAndSwapInt32((*int32)(lock), 0, 1) {
+	for !acquire() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
 		for range backoff {
 			runtime.Gosched() // Yield processor
 		}
@@ -60,30 +133,178 @@ func (kl *KeyLock) Lock(key string) {
 			backoff <<= 1 // Exponential backoff
 		}
 	}
+	return nil
+}
+
+// TryLock attempts to acquire the lock for the given key immediately.
+// Returns true if the lock was successfully acquired, false otherwise.
+func (kl *KeyLock) TryLock(key string) bool {
+	lock := kl.resolve(key)
+	defer atomic.AddInt32(&lock.waiters, -1)
+
+	acquired := atomic.CompareAndSwapInt64(&lock.state, 0, -1)
+	if acquired {
+		atomic.AddInt32(&kl.count, 1)
+	}
+	return acquired
+}
+
+// Lock acquires the lock for the given key, using exponential backoff if
+// necessary. In reentrant mode (see NewReentrant), a goroutine that
+// already holds key may call Lock on it again without blocking.
+func (kl *KeyLock) Lock(key string) {
+	if kl.reentrant {
+		kl.lockReentrant(key)
+		return
+	}
+	// LockCtx with a background context can never be cancelled or time out,
+	// so the error return is always nil.
+	_ = kl.LockCtx(context.Background(), key)
+}
+
+// LockCtx acquires the lock for the given key, using exponential backoff
+// if necessary, but aborts early if ctx is cancelled or its deadline fires.
+// Returns ctx.Err() (context.Canceled or context.DeadlineExceeded) on abort,
+// nil once the lock is held.
+func (kl *KeyLock) LockCtx(ctx context.Context, key string) error {
+	lock := kl.resolve(key)
+	defer atomic.AddInt32(&lock.waiters, -1)
+
+	atomic.AddInt32(&lock.writersWaiting, 1)
+	defer atomic.AddInt32(&lock.writersWaiting, -1)
+
+	if err := kl.spin(ctx, lock, func() bool {
+		return atomic.CompareAndSwapInt64(&lock.state, 0, -1)
+	}); err != nil {
+		return err
+	}
 	atomic.AddInt32(&kl.count, 1)
+	return nil
+}
+
+// TryLockTimeout attempts to acquire the lock for the given key, giving up
+// and returning false if it isn't acquired within d.
+func (kl *KeyLock) TryLockTimeout(key string, d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return kl.LockCtx(ctx, key) == nil
 }
 
-// Unlock releases the lock associated with the given key.
+// Unlock releases the lock associated with the given key. In reentrant
+// mode, it decrements the calling goroutine's hold depth and only
+// releases the underlying lock once that depth reaches zero.
 func (kl *KeyLock) Unlock(key string) {
-	if value, ok := kl.locks.Load(key); ok {
-		lock := value.(*spinLock)
-		atomic.StoreInt32((*int32)(lock), 0)
+	if kl.reentrant {
+		kl.unlockReentrant(key)
+		return
+	}
+	if lock, ok := kl.find(key); ok {
+		atomic.StoreInt64(&lock.state, 0)
 		atomic.AddInt32(&kl.count, -1)
+		kl.releaseIfIdle(key, lock)
+	}
+}
+
+// TryRLock attempts to acquire a read lock for the given key immediately.
+// It succeeds as long as no writer currently holds the key and none is
+// waiting to acquire it, and can be held concurrently with other readers.
+// Returns true on success.
+func (kl *KeyLock) TryRLock(key string) bool {
+	lock := kl.resolve(key)
+	defer atomic.AddInt32(&lock.waiters, -1)
+
+	for {
+		cur := atomic.LoadInt64(&lock.state)
+		if cur < 0 || atomic.LoadInt32(&lock.writersWaiting) > 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&lock.state, cur, cur+1) {
+			atomic.AddInt32(&kl.readCount, 1)
+			return true
+		}
 	}
 }
 
-// Size returns the number of currently active (held) locks.
+// RLock acquires a read lock for the given key, using the same
+// exponential-backoff spin loop as Lock, but only blocks while a writer
+// holds or is waiting to acquire the key; any number of readers may hold
+// it at once. Readers back off as soon as a writer starts waiting, rather
+// than winning every race against it, so a steady stream of overlapping
+// readers can't starve a writer out indefinitely.
+//
+// As with sync.RWMutex, a goroutine must not call RLock while it already
+// holds a read lock on the same key if another goroutine might be
+// blocked in Lock on it: once that writer is waiting, this RLock call
+// would itself block behind it, and since nothing but the first RLock's
+// RUnlock can let the writer through, the calling goroutine would
+// deadlock against its own held lock.
+func (kl *KeyLock) RLock(key string) {
+	lock := kl.resolve(key)
+	defer atomic.AddInt32(&lock.waiters, -1)
+
+	_ = kl.spin(context.Background(), lock, func() bool {
+		cur := atomic.LoadInt64(&lock.state)
+		return cur >= 0 && atomic.LoadInt32(&lock.writersWaiting) == 0 && atomic.CompareAndSwapInt64(&lock.state, cur, cur+1)
+	})
+	atomic.AddInt32(&kl.readCount, 1)
+}
+
+// RUnlock releases a read lock previously acquired with RLock or TryRLock.
+// An extra/unmatched RUnlock call on a key with no readers left is a no-op,
+// the same as Unlock on a key nobody holds, rather than driving state
+// negative and corrupting another goroutine's still-outstanding read lock.
+func (kl *KeyLock) RUnlock(key string) {
+	lock, ok := kl.find(key)
+	if !ok {
+		return
+	}
+	for {
+		cur := atomic.LoadInt64(&lock.state)
+		if cur <= 0 {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&lock.state, cur, cur-1) {
+			atomic.AddInt32(&kl.readCount, -1)
+			kl.releaseIfIdle(key, lock)
+			return
+		}
+	}
+}
+
+// Size returns the number of currently active (held) locks, read and write combined.
 func (kl *KeyLock) Size() int {
+	return kl.WriteSize() + kl.ReadSize()
+}
+
+// WriteSize returns the number of currently held write locks.
+func (kl *KeyLock) WriteSize() int {
 	return int(atomic.LoadInt32(&kl.count))
 }
 
+// ReadSize returns the number of currently held read locks.
+func (kl *KeyLock) ReadSize() int {
+	return int(atomic.LoadInt32(&kl.readCount))
+}
+
 // Cleanup removes all unused (unlocked) locks from the map.
-// This is optional and can be used to free memory.
+// An entry is only removed when it's unlocked and no goroutine is
+// currently waiting on it, so a waiter can never CAS against a
+// *spinLock that Cleanup has just unlinked from the map.
+//
+// Lock/Unlock and RLock/RUnlock already remove a key's entry as soon as
+// it goes idle, so Cleanup is mostly redundant now; it's kept, and safe
+// to call at any time (including on an empty KeyLock), for callers
+// upgrading from before that was true.
 func (kl *KeyLock) Cleanup() {
-	kl.locks.Range(func(key, value any) bool {
-		if atomic.LoadInt32((*int32)(value.(*spinLock))) == 0 {
-			kl.locks.Delete(key)
+	kl.ensureInit()
+	for i := range kl.shards {
+		s := &kl.shards[i]
+		s.mu.Lock()
+		for key, lock := range s.locks {
+			if atomic.LoadInt64(&lock.state) == 0 && atomic.LoadInt32(&lock.waiters) == 0 {
+				delete(s.locks, key)
+			}
 		}
-		return true
-	})
+		s.mu.Unlock()
+	}
 }
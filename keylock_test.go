@@ -1,7 +1,10 @@
 package keylock
 
 import (
+	"context"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -132,6 +135,209 @@ func TestKeyLockDifferentKeysConcurrently(t *testing.T) {
 	assert.Equal(t, 0, kl.Size(), "All locks should be released")
 }
 
+func TestKeyLockCtxCancelled(t *testing.T) {
+	kl := New()
+	kl.Lock("test1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- kl.LockCtx(ctx, "test1")
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	err := <-done
+	assert.ErrorIs(t, err, context.Canceled, "LockCtx should abort with context.Canceled")
+
+	kl.Unlock("test1")
+	assert.Equal(t, 0, kl.Size(), "Count should be 0 after unlocking")
+}
+
+func TestKeyLockCtxDeadlineExceeded(t *testing.T) {
+	kl := New()
+	kl.Lock("test1")
+	defer kl.Unlock("test1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := kl.LockCtx(ctx, "test1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "LockCtx should abort with context.DeadlineExceeded")
+}
+
+func TestKeyLockTryLockTimeout(t *testing.T) {
+	kl := New()
+	kl.Lock("test1")
+	defer kl.Unlock("test1")
+
+	acquired := kl.TryLockTimeout("test1", 20*time.Millisecond)
+	assert.False(t, acquired, "TryLockTimeout should fail while the key is held")
+
+	acquired = kl.TryLockTimeout("test2", 20*time.Millisecond)
+	assert.True(t, acquired, "TryLockTimeout should succeed on an unheld key")
+	kl.Unlock("test2")
+}
+
+func TestKeyLockCtxNoGoroutineLeak(t *testing.T) {
+	kl := New()
+	kl.Lock("contended")
+
+	before := runtime.NumGoroutine()
+
+	const numWaiters = 50
+	var wg sync.WaitGroup
+	wg.Add(numWaiters)
+	for i := 0; i < numWaiters; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			err := kl.LockCtx(ctx, "contended")
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		}()
+	}
+	wg.Wait()
+
+	kl.Unlock("contended")
+
+	assert.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= before+2
+	}, time.Second, 10*time.Millisecond, "waiting goroutines should not leak after context cancellation")
+}
+
+func TestKeyLockRLockConcurrentReaders(t *testing.T) {
+	kl := New()
+
+	kl.RLock("test1")
+	kl.RLock("test1")
+	assert.Equal(t, 2, kl.ReadSize(), "Two readers should be able to hold the same key")
+	assert.Equal(t, 0, kl.WriteSize())
+	assert.Equal(t, 2, kl.Size())
+
+	kl.RUnlock("test1")
+	kl.RUnlock("test1")
+	assert.Equal(t, 0, kl.ReadSize(), "All readers released")
+}
+
+func TestKeyLockRLockBlocksOnWriter(t *testing.T) {
+	kl := New()
+	kl.Lock("test1")
+
+	var acquired atomic.Bool
+	go func() {
+		kl.RLock("test1")
+		acquired.Store(true)
+		kl.RUnlock("test1")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, acquired.Load(), "RLock should block while a writer holds the key")
+
+	kl.Unlock("test1")
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, acquired.Load(), "RLock should succeed once the writer releases")
+}
+
+func TestKeyLockWriterBlocksOnReaders(t *testing.T) {
+	kl := New()
+	kl.RLock("test1")
+
+	var acquired atomic.Bool
+	go func() {
+		kl.Lock("test1")
+		acquired.Store(true)
+		kl.Unlock("test1")
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, acquired.Load(), "Lock should block while a reader holds the key")
+
+	kl.RUnlock("test1")
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, acquired.Load(), "Lock should succeed once all readers release")
+}
+
+func TestKeyLockTryRLock(t *testing.T) {
+	kl := New()
+
+	assert.True(t, kl.TryRLock("test1"))
+	assert.True(t, kl.TryRLock("test1"), "TryRLock should succeed while only readers hold the key")
+	assert.Equal(t, 2, kl.ReadSize())
+
+	kl.RUnlock("test1")
+	kl.RUnlock("test1")
+
+	kl.Lock("test2")
+	assert.False(t, kl.TryRLock("test2"), "TryRLock should fail while a writer holds the key")
+	kl.Unlock("test2")
+}
+
+func TestKeyLockRUnlockExtraCallIsNoOp(t *testing.T) {
+	kl := New()
+
+	kl.RLock("test1")
+	kl.RLock("test1")
+	kl.RUnlock("test1")
+	assert.Equal(t, 1, kl.ReadSize(), "one reader should still hold the key")
+
+	// Release the remaining reader, then make one extra RUnlock past the
+	// matching RLock calls; it must not drive the key's state negative.
+	kl.RUnlock("test1")
+	kl.RUnlock("test1")
+	assert.Equal(t, 0, kl.ReadSize())
+
+	assert.True(t, kl.TryLock("test1"), "the key should be fully released, not corrupted, after the extra RUnlock calls")
+	kl.Unlock("test1")
+
+	kl.RUnlock("test1")
+	assert.Equal(t, 0, kl.ReadSize(), "RUnlock on an already-idle key should remain a no-op")
+}
+
+func TestKeyLockWriterNotStarvedByReaders(t *testing.T) {
+	kl := New()
+	kl.RLock("test1")
+
+	writerDone := make(chan struct{})
+	go func() {
+		kl.Lock("test1")
+		close(writerDone)
+		kl.Unlock("test1")
+	}()
+
+	// Give the writer time to start waiting, then keep a steady stream of
+	// new readers arriving; none of them should be able to jump ahead of
+	// the waiting writer.
+	time.Sleep(20 * time.Millisecond)
+	stop := make(chan struct{})
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if kl.TryRLock("test1") {
+				kl.RUnlock("test1")
+			}
+		}
+	}()
+
+	kl.RUnlock("test1")
+
+	select {
+	case <-writerDone:
+	case <-time.After(time.Second):
+		t.Fatal("writer starved by a steady stream of readers")
+	}
+
+	close(stop)
+	<-readerDone
+}
+
 func TestKeyLockNestedLocks(t *testing.T) {
 	kl := New()
 
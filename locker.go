@@ -0,0 +1,20 @@
+package keylock
+
+// Locker is satisfied by anything that coordinates exclusive and shared
+// access to a set of string keys, whether the backing state lives
+// in-process (KeyLock) or on a remote coordinator (EtcdKeyLock). Code that
+// depends only on Locker can move from a single-process deployment to a
+// distributed one without changing call sites.
+type Locker interface {
+	Lock(key string)
+	TryLock(key string) bool
+	Unlock(key string)
+	RLock(key string)
+	TryRLock(key string) bool
+	RUnlock(key string)
+}
+
+var (
+	_ Locker = (*KeyLock)(nil)
+	_ Locker = (*EtcdKeyLock)(nil)
+)
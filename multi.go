@@ -0,0 +1,59 @@
+package keylock
+
+import "sort"
+
+// LockMulti acquires the lock for every key in keys, deduplicating and
+// sorting them into a canonical order first so that any two callers
+// locking overlapping sets always acquire the shared keys in the same
+// order. That eliminates the AB/BA deadlock that locking the keys in
+// caller-supplied order would risk whenever two goroutines disagree on
+// which key to take first.
+func (kl *KeyLock) LockMulti(keys ...string) {
+	for _, key := range canonicalKeys(keys) {
+		kl.Lock(key)
+	}
+}
+
+// UnlockMulti releases the locks acquired by a matching LockMulti call,
+// releasing them in the reverse of LockMulti's acquisition order.
+func (kl *KeyLock) UnlockMulti(keys ...string) {
+	ordered := canonicalKeys(keys)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		kl.Unlock(ordered[i])
+	}
+}
+
+// TryLockMulti attempts to acquire every key in keys, in the same
+// canonical order as LockMulti. If any key is already held, it rolls
+// back every key it had acquired so far and returns false; callers never
+// observe a partial acquisition.
+func (kl *KeyLock) TryLockMulti(keys ...string) bool {
+	ordered := canonicalKeys(keys)
+	acquired := make([]string, 0, len(ordered))
+	for _, key := range ordered {
+		if !kl.TryLock(key) {
+			for i := len(acquired) - 1; i >= 0; i-- {
+				kl.Unlock(acquired[i])
+			}
+			return false
+		}
+		acquired = append(acquired, key)
+	}
+	return true
+}
+
+// canonicalKeys dedupes and sorts keys into the fixed order LockMulti,
+// UnlockMulti and TryLockMulti acquire/release them in.
+func canonicalKeys(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	ordered := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		ordered = append(ordered, key)
+	}
+	sort.Strings(ordered)
+	return ordered
+}
@@ -0,0 +1,83 @@
+package keylock
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockMultiDedupesAndOrders(t *testing.T) {
+	kl := New()
+
+	assert.Equal(t, []string{"a", "b", "c"}, canonicalKeys([]string{"b", "a", "a", "c"}), "keys should be deduplicated and sorted")
+
+	kl.LockMulti("b", "a", "a", "c")
+	assert.Equal(t, 3, kl.Size(), "duplicate keys should only be locked once")
+
+	kl.UnlockMulti("b", "a", "a", "c")
+	assert.Equal(t, 0, kl.Size())
+}
+
+func TestTryLockMultiRollsBackOnFailure(t *testing.T) {
+	kl := New()
+	kl.Lock("b")
+
+	ok := kl.TryLockMulti("a", "b", "c")
+	assert.False(t, ok, "TryLockMulti should fail if any key is already held")
+	assert.Equal(t, 1, kl.Size(), "keys acquired before the failing one must be rolled back")
+
+	kl.Unlock("b")
+
+	ok = kl.TryLockMulti("a", "b", "c")
+	assert.True(t, ok, "TryLockMulti should succeed once nothing is held")
+	assert.Equal(t, 3, kl.Size())
+	kl.UnlockMulti("a", "b", "c")
+}
+
+// TestLockMultiNoDeadlockUnderOverlappingKeys stresses many goroutines
+// locking random, overlapping subsets of a small keyspace in arbitrary
+// caller order. LockMulti's canonical ordering should make this
+// deadlock-free; if it isn't, the test times out instead of finishing.
+func TestLockMultiNoDeadlockUnderOverlappingKeys(t *testing.T) {
+	kl := New()
+	keyspace := []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7"}
+
+	const numGoroutines = 64
+	const numRounds = 50
+
+	var wg sync.WaitGroup
+	wg.Add(numGoroutines)
+	for i := 0; i < numGoroutines; i++ {
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for r := 0; r < numRounds; r++ {
+				n := 1 + rnd.Intn(len(keyspace))
+				perm := rnd.Perm(len(keyspace))[:n]
+				keys := make([]string, n)
+				for j, idx := range perm {
+					keys[j] = keyspace[idx]
+				}
+				kl.LockMulti(keys...)
+				kl.UnlockMulti(keys...)
+			}
+		}(int64(i))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("LockMulti deadlocked under overlapping concurrent acquisitions")
+	}
+
+	assert.Equal(t, 0, kl.Size())
+}
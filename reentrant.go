@@ -0,0 +1,95 @@
+package keylock
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// NewReentrant creates a KeyLock whose Lock/Unlock are reentrant: a
+// goroutine that already holds a key may call Lock on it again without
+// deadlocking, as long as it calls Unlock the same number of times before
+// the key is released to other goroutines.
+//
+// Ownership is tracked by goroutine ID, recovered by parsing the header
+// line of runtime.Stack's output, rather than via a LockToken returned
+// from Lock. That keeps call sites identical to the non-reentrant API
+// (no token to thread through), at the cost of depending on the format of
+// runtime.Stack, which the Go runtime doesn't guarantee not to change.
+// TryLock, RLock and TryRLock are unaffected; they have no notion of an
+// owning goroutine and behave as they do on a non-reentrant KeyLock.
+func NewReentrant() *KeyLock {
+	return New().WithReentrant(true)
+}
+
+// WithReentrant enables or disables reentrant Lock/Unlock. See NewReentrant.
+func (kl *KeyLock) WithReentrant(enabled bool) *KeyLock {
+	kl.reentrant = enabled
+	return kl
+}
+
+func (kl *KeyLock) lockReentrant(key string) {
+	lock := kl.resolve(key)
+	defer atomic.AddInt32(&lock.waiters, -1)
+	gid := goroutineID()
+
+	lock.mu.Lock()
+	if atomic.LoadInt64(&lock.state) == -1 && lock.owner == gid {
+		lock.depth++
+		lock.mu.Unlock()
+		return
+	}
+	lock.mu.Unlock()
+
+	atomic.AddInt32(&lock.writersWaiting, 1)
+	_ = kl.spin(context.Background(), lock, func() bool {
+		return atomic.CompareAndSwapInt64(&lock.state, 0, -1)
+	})
+	atomic.AddInt32(&lock.writersWaiting, -1)
+	atomic.AddInt32(&kl.count, 1)
+
+	lock.mu.Lock()
+	lock.owner = gid
+	lock.depth = 1
+	lock.mu.Unlock()
+}
+
+func (kl *KeyLock) unlockReentrant(key string) {
+	lock, ok := kl.find(key)
+	if !ok {
+		return
+	}
+	gid := goroutineID()
+
+	lock.mu.Lock()
+	if lock.owner != gid {
+		// Not the owner: same as unlocking a key nobody holds, ignore.
+		lock.mu.Unlock()
+		return
+	}
+	lock.depth--
+	if lock.depth > 0 {
+		lock.mu.Unlock()
+		return
+	}
+	lock.owner = 0
+	lock.mu.Unlock()
+
+	atomic.StoreInt64(&lock.state, 0)
+	atomic.AddInt32(&kl.count, -1)
+	kl.releaseIfIdle(key, lock)
+}
+
+// goroutineID recovers the ID of the calling goroutine by parsing the
+// "goroutine 123 [running]:" header runtime.Stack prints. There's no
+// public API for this; it's the same technique used by a number of
+// goroutine-local-storage shims in the wild.
+func goroutineID() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	field := bytes.Fields(buf[:n])[1]
+	id, _ := strconv.ParseInt(string(field), 10, 64)
+	return id
+}
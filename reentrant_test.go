@@ -0,0 +1,89 @@
+package keylock
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReentrantSameGoroutineDoesNotDeadlock(t *testing.T) {
+	kl := NewReentrant()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		kl.Lock("res")
+		kl.Lock("res")
+		kl.Lock("res")
+		assert.Equal(t, 1, kl.Size(), "Size should count the key once regardless of recursion depth")
+
+		kl.Unlock("res")
+		kl.Unlock("res")
+		assert.Equal(t, 1, kl.Size(), "lock should still be held until the outermost Unlock")
+
+		kl.Unlock("res")
+		assert.Equal(t, 0, kl.Size(), "lock should be released after matching every Lock with an Unlock")
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reentrant Lock deadlocked")
+	}
+}
+
+func TestReentrantCrossGoroutineBlocks(t *testing.T) {
+	kl := NewReentrant()
+	kl.Lock("res")
+
+	acquired := make(chan struct{})
+	go func() {
+		kl.Lock("res")
+		close(acquired)
+		kl.Unlock("res")
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("a different goroutine should not be able to acquire a key another goroutine holds")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	kl.Unlock("res")
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("lock should become available once the owning goroutine releases it")
+	}
+}
+
+func TestReentrantUnlockByNonOwnerIsIgnored(t *testing.T) {
+	kl := NewReentrant()
+	kl.Lock("res")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		kl.Unlock("res") // not the owner; must not release the lock
+	}()
+	wg.Wait()
+
+	assert.Equal(t, 1, kl.Size(), "Unlock from a non-owning goroutine must not release the lock")
+	kl.Unlock("res")
+	assert.Equal(t, 0, kl.Size())
+}
+
+func TestReentrantIndependentKeysOnSameGoroutine(t *testing.T) {
+	kl := NewReentrant()
+
+	kl.Lock("outer")
+	kl.Lock("inner")
+	assert.Equal(t, 2, kl.Size())
+
+	kl.Unlock("inner")
+	kl.Unlock("outer")
+	assert.Equal(t, 0, kl.Size())
+}
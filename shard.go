@@ -0,0 +1,88 @@
+package keylock
+
+import "sync"
+
+const (
+	defaultShardCount = 16 // default number of shards, must stay a power of two
+
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// shard is one partition of a KeyLock's keyspace: its own mutex-guarded
+// map so that unrelated keys in different shards never contend with each
+// other. The mutex (rather than a sync.Map) lets resolve pair "find or
+// create the entry" with "record a waiter on it" as a single atomic step,
+// which is what keeps releaseIfIdle from ever deleting an entry out from
+// under a goroutine that's about to start waiting on it.
+type shard struct {
+	mu    sync.Mutex
+	locks map[string]*spinLock
+}
+
+// newShards allocates n shards.
+func newShards(n int) []shard {
+	shards := make([]shard, n)
+	for i := range shards {
+		shards[i].locks = make(map[string]*spinLock)
+	}
+	return shards
+}
+
+// nextPowerOfTwo rounds n up to the nearest power of two, with a floor of 1.
+func nextPowerOfTwo(n int) int {
+	if n <= 1 {
+		return 1
+	}
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// fnv32 is a zero-allocation FNV-1a 32-bit hash, used to pick the shard
+// for a key.
+func fnv32(key string) uint32 {
+	h := uint32(fnvOffset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= fnvPrime32
+	}
+	return h
+}
+
+// WithShards sets the number of shards the keyspace is partitioned across,
+// rounding up to the next power of two (minimum 1). More shards reduce
+// contention on the underlying maps under high key cardinality, at the
+// cost of a little extra memory. It must be called before any key is
+// locked; resharding a KeyLock that's already in use would scatter
+// in-flight *spinLock entries across the old and new layouts.
+func (kl *KeyLock) WithShards(n int) *KeyLock {
+	n = nextPowerOfTwo(n)
+	kl.shards = newShards(n)
+	kl.shardMask = uint32(n - 1)
+	return kl
+}
+
+// shardFor returns the shard that owns key, lazily initializing kl's
+// shards to the default layout first if it's a zero-value KeyLock that
+// wasn't built through New().
+func (kl *KeyLock) shardFor(key string) *shard {
+	kl.ensureInit()
+	return &kl.shards[fnv32(key)&kl.shardMask]
+}
+
+// ensureInit makes a zero-value KeyLock (one constructed as &KeyLock{}
+// rather than via New()) usable, the same way a zero-value sync.Mutex is.
+func (kl *KeyLock) ensureInit() {
+	kl.initOnce.Do(func() {
+		if kl.shards == nil {
+			kl.shards = newShards(defaultShardCount)
+			kl.shardMask = uint32(defaultShardCount - 1)
+		}
+		if kl.maxSpins == 0 {
+			kl.maxSpins = defaultMaxSpins
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package keylock
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithShardsRoundsUpToPowerOfTwo(t *testing.T) {
+	kl := New().WithShards(10)
+	assert.Len(t, kl.shards, 16, "10 shards should round up to 16")
+
+	kl = New().WithShards(1)
+	assert.Len(t, kl.shards, 1)
+
+	kl = New().WithShards(0)
+	assert.Len(t, kl.shards, 1, "shard count should floor at 1")
+}
+
+func TestUnlockRemovesIdleEntryAutomatically(t *testing.T) {
+	kl := New()
+
+	kl.Lock("test1")
+	_, ok := kl.find("test1")
+	assert.True(t, ok, "the entry should exist while the key is held")
+
+	kl.Unlock("test1")
+	_, ok = kl.find("test1")
+	assert.False(t, ok, "Unlock should remove the entry once the key goes idle")
+}
+
+func TestRUnlockRemovesIdleEntryAutomatically(t *testing.T) {
+	kl := New()
+
+	kl.RLock("test1")
+	kl.RLock("test1")
+	kl.RUnlock("test1")
+	_, ok := kl.find("test1")
+	assert.True(t, ok, "the entry should survive while a reader still holds it")
+
+	kl.RUnlock("test1")
+	_, ok = kl.find("test1")
+	assert.False(t, ok, "RUnlock should remove the entry once the last reader releases it")
+}
+
+func TestZeroValueKeyLockIsUsable(t *testing.T) {
+	var kl KeyLock
+
+	kl.Lock("test1")
+	assert.Equal(t, 1, kl.Size())
+	kl.Unlock("test1")
+	assert.Equal(t, 0, kl.Size())
+}
+
+func TestCleanupIsSafeWithNothingToClean(t *testing.T) {
+	kl := New()
+	assert.NotPanics(t, func() {
+		kl.Cleanup()
+	})
+
+	kl.Lock("test1")
+	kl.Cleanup()
+	assert.Equal(t, 1, kl.Size(), "Cleanup must not touch a held lock")
+	kl.Unlock("test1")
+}
+
+// BenchmarkKeyLockShards compares contention across 1 (a single map
+// holding every key), 8 and 64 shards on a workload of 1M distinct keys
+// locked and unlocked concurrently.
+func BenchmarkKeyLockShards(b *testing.B) {
+	const numKeys = 1_000_000
+
+	for _, shards := range []int{1, 8, 64} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			kl := New().WithShards(shards)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := fmt.Sprintf("key-%d", i%numKeys)
+					kl.Lock(key)
+					kl.Unlock(key)
+					i++
+				}
+			})
+		})
+	}
+}